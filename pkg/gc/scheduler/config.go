@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ConfigMapName is the name of the ConfigMap in the dev namespace that
+// configures the gc controller when no GCSchedule CRD is installed
+const ConfigMapName = "jx-gc-schedule"
+
+// configMapDataKey is the key inside the ConfigMap holding the YAML schedule
+const configMapDataKey = "schedule.yaml"
+
+// statusDataKey is the key inside the ConfigMap holding the YAML status of
+// the most recent run of each task, keyed by TaskConfig.Name
+const statusDataKey = "status.yaml"
+
+// SupportedTaskTypes are the TaskConfig.Type values ControllerGCOptions.runTask
+// knows how to run. This gc controller is intentionally scoped to activities
+// only for now: previews/helm/pods gc, and the Helm chart/RBAC changes needed
+// to deploy this controller with delete rights on pipelineactivities, are a
+// separate, follow-up piece of work rather than being silently half-wired
+var SupportedTaskTypes = map[string]bool{
+	"activities": true,
+}
+
+// Config is the set of GCTasks the controller should run, loaded either from
+// a GCSchedule CRD or from the jx-gc-schedule ConfigMap
+type Config struct {
+	Tasks []TaskConfig `json:"tasks"`
+}
+
+// TaskConfig configures a single garbage collection task to run on a cron schedule
+type TaskConfig struct {
+	// Name uniquely identifies this task within the schedule
+	Name string `json:"name"`
+	// Type is the kind of garbage collection to run. Must be one of
+	// SupportedTaskTypes; LoadConfigMap rejects anything else so a
+	// misconfigured schedule fails fast at startup rather than at its first
+	// cron fire
+	Type string `json:"type"`
+	// Cron is the cron spec understood by robfig/cron, e.g. "@every 24h" or "0 2 * * *"
+	Cron string `json:"cron"`
+	// RunAtStart runs the task once immediately when the controller starts, in addition to its cron schedule
+	RunAtStart bool `json:"runAtStart,omitempty"`
+	// RevisionHistoryLimit is passed through to `jx gc activities --revision-history-limit`
+	RevisionHistoryLimit int `json:"revisionHistoryLimit,omitempty"`
+	// PullRequestHours is passed through to `jx gc activities --pull-request-hours`
+	PullRequestHours int `json:"pullRequestHours,omitempty"`
+}
+
+// LoadConfigMap loads the gc schedule Config from the jx-gc-schedule ConfigMap
+// in the given namespace, and validates that every task's Type is supported
+func LoadConfigMap(kubeClient kubernetes.Interface, ns string) (*Config, error) {
+	cm, err := kubeClient.CoreV1().ConfigMaps(ns).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ConfigMap %s in namespace %s: %v", ConfigMapName, ns, err)
+	}
+
+	data := cm.Data[configMapDataKey]
+	if data == "" {
+		return nil, fmt.Errorf("ConfigMap %s in namespace %s has no %s entry", ConfigMapName, ns, configMapDataKey)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s from ConfigMap %s: %v", configMapDataKey, ConfigMapName, err)
+	}
+
+	for _, task := range config.Tasks {
+		if !SupportedTaskTypes[task.Type] {
+			return nil, fmt.Errorf("task %s in ConfigMap %s has unsupported type %q, supported types are %s", task.Name, ConfigMapName, task.Type, supportedTaskTypesList())
+		}
+	}
+
+	return config, nil
+}
+
+func supportedTaskTypesList() string {
+	types := make([]string, 0, len(SupportedTaskTypes))
+	for t := range SupportedTaskTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// TaskStatus is the externally visible, serialisable form of scheduler.Status
+// for a single task, persisted into the jx-gc-schedule ConfigMap so its last
+// run time and error can be inspected with `kubectl get configmap
+// jx-gc-schedule -o yaml`, not just from Scheduler.Status inside the running
+// controller
+type TaskStatus struct {
+	LastRunTime time.Time `json:"lastRunTime,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// SaveTaskStatus persists the most recent run outcome of a single task back
+// into the jx-gc-schedule ConfigMap's status.yaml entry, merging it with the
+// statuses already recorded for other tasks
+func SaveTaskStatus(kubeClient kubernetes.Interface, ns, taskName string, lastRunTime time.Time, lastErr error) error {
+	cm, err := kubeClient.CoreV1().ConfigMaps(ns).Get(ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to find ConfigMap %s in namespace %s: %v", ConfigMapName, ns, err)
+	}
+
+	statuses := map[string]TaskStatus{}
+	if existing := cm.Data[statusDataKey]; existing != "" {
+		if err := yaml.Unmarshal([]byte(existing), &statuses); err != nil {
+			return fmt.Errorf("failed to parse existing %s from ConfigMap %s: %v", statusDataKey, ConfigMapName, err)
+		}
+	}
+
+	status := TaskStatus{LastRunTime: lastRunTime}
+	if lastErr != nil {
+		status.LastError = lastErr.Error()
+	}
+	statuses[taskName] = status
+
+	data, err := yaml.Marshal(statuses)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gc task status: %v", err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[statusDataKey] = string(data)
+
+	if _, err := kubeClient.CoreV1().ConfigMaps(ns).Update(cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s status: %v", ConfigMapName, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package scheduler_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jenkins-x/jx/pkg/gc/scheduler"
+)
+
+func TestLoadConfigMapRejectsUnsupportedTaskType(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: scheduler.ConfigMapName, Namespace: "jx"},
+		Data: map[string]string{"schedule.yaml": `
+tasks:
+- name: nightly-previews
+  type: previews
+  cron: "@every 24h"
+`},
+	})
+
+	_, err := scheduler.LoadConfigMap(kubeClient, "jx")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "nightly-previews")
+	assert.Contains(t, err.Error(), `"previews"`)
+	assert.Contains(t, err.Error(), "activities", "the error should list which task types are actually supported")
+}
+
+func TestLoadConfigMapAcceptsSupportedTaskType(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: scheduler.ConfigMapName, Namespace: "jx"},
+		Data: map[string]string{"schedule.yaml": `
+tasks:
+- name: nightly-activities
+  type: activities
+  cron: "@every 24h"
+`},
+	})
+
+	config, err := scheduler.LoadConfigMap(kubeClient, "jx")
+	assert.NoError(t, err)
+	assert.Len(t, config.Tasks, 1)
+}
+
+func TestSaveTaskStatusPersistsToConfigMap(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: scheduler.ConfigMapName, Namespace: "jx"},
+		Data:       map[string]string{"schedule.yaml": "tasks: []"},
+	})
+
+	runTime := time.Now().Truncate(time.Second)
+	err := scheduler.SaveTaskStatus(kubeClient, "jx", "activities", runTime, nil)
+	assert.NoError(t, err)
+
+	err = scheduler.SaveTaskStatus(kubeClient, "jx", "previews", runTime, errors.New("boom"))
+	assert.NoError(t, err)
+
+	cm, err := kubeClient.CoreV1().ConfigMaps("jx").Get(scheduler.ConfigMapName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, cm.Data["status.yaml"], "activities")
+	assert.Contains(t, cm.Data["status.yaml"], "previews")
+	assert.Contains(t, cm.Data["status.yaml"], "boom")
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/jenkins-x/jx/pkg/log"
+)
+
+// TaskFunc performs a single garbage collection run and returns an error if it failed
+type TaskFunc func() error
+
+// Task is a named unit of work run on a cron schedule
+type Task struct {
+	Name       string
+	Cron       string
+	RunAtStart bool
+	Func       TaskFunc
+	// OnStatus, if set, is called with a snapshot of the task's Status after
+	// every run, letting callers mirror it somewhere visible outside the
+	// process (e.g. back into a ConfigMap) in addition to Scheduler.Status
+	OnStatus func(Status)
+}
+
+// Status captures the outcome of the most recent run of a Task
+type Status struct {
+	Running     bool
+	LastRunTime time.Time
+	LastError   error
+}
+
+// Scheduler runs a set of Tasks on their configured cron schedules. If a
+// task is still running when its next fire comes around the fire is skipped,
+// mirroring the "if running, return" guard used elsewhere for archive cleanup
+type Scheduler struct {
+	cron *cron.Cron
+
+	mu       sync.Mutex
+	statuses map[string]*Status
+}
+
+// New creates a Scheduler ready to have tasks added to it
+func New() *Scheduler {
+	return &Scheduler{
+		cron:     cron.New(),
+		statuses: map[string]*Status{},
+	}
+}
+
+// AddTask registers a Task with the scheduler. Call this before Start
+func (s *Scheduler) AddTask(task Task) error {
+	s.mu.Lock()
+	s.statuses[task.Name] = &Status{}
+	s.mu.Unlock()
+
+	_, err := s.cron.AddFunc(task.Cron, func() {
+		s.runTask(task)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule task %s with cron spec %q: %v", task.Name, task.Cron, err)
+	}
+
+	if task.RunAtStart {
+		go s.runTask(task)
+	}
+	return nil
+}
+
+// Start begins running the scheduled tasks in the background
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops the scheduler from firing any new tasks and blocks until any
+// tasks already running finish: cron fires via the context cron.Cron.Stop()
+// returns, and RunAtStart runs, which cron does not track, by waiting until
+// no task's Status reports Running
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+	for {
+		s.mu.Lock()
+		running := false
+		for _, st := range s.statuses {
+			running = running || st.Running
+		}
+		s.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Status returns a snapshot of the last run outcome for the named task
+func (s *Scheduler) Status(name string) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.statuses[name]; ok {
+		return *st
+	}
+	return Status{}
+}
+
+func (s *Scheduler) runTask(task Task) {
+	s.mu.Lock()
+	st := s.statuses[task.Name]
+	if st.Running {
+		s.mu.Unlock()
+		log.Infof("gc scheduler: task %s is still running, skipping this fire\n", task.Name)
+		return
+	}
+	st.Running = true
+	s.mu.Unlock()
+
+	err := task.Func()
+
+	s.mu.Lock()
+	st.Running = false
+	st.LastRunTime = time.Now()
+	st.LastError = err
+	status := *st
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Errorf("gc scheduler: task %s failed: %v\n", task.Name, err)
+	}
+
+	if task.OnStatus != nil {
+		task.OnStatus(status)
+	}
+}
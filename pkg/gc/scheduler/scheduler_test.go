@@ -0,0 +1,109 @@
+package scheduler_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jenkins-x/jx/pkg/gc/scheduler"
+)
+
+func TestSchedulerSkipsOverlappingFires(t *testing.T) {
+	s := scheduler.New()
+
+	var running int32
+	var overlapped int32
+
+	err := s.AddTask(scheduler.Task{
+		Name: "slow",
+		// robfig/cron rounds "@every" delays up to at least one second, so the
+		// task runs for longer than that to be still running at its next fire
+		Cron: "@every 1s",
+		Func: func() error {
+			if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+				atomic.AddInt32(&overlapped, 1)
+				return nil
+			}
+			time.Sleep(1500 * time.Millisecond)
+			atomic.StoreInt32(&running, 0)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	s.Start()
+	time.Sleep(3500 * time.Millisecond)
+	s.Stop()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlapped), "overlapping fires of a still-running task should be skipped by the scheduler, not by the task itself")
+}
+
+func TestSchedulerRunAtStart(t *testing.T) {
+	s := scheduler.New()
+
+	done := make(chan struct{})
+	err := s.AddTask(scheduler.Task{
+		Name:       "immediate",
+		Cron:       "@every 1h",
+		RunAtStart: true,
+		Func: func() error {
+			close(done)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected RunAtStart task to run without waiting for its cron schedule")
+	}
+}
+
+func TestSchedulerStopWaitsForRunningTaskToFinish(t *testing.T) {
+	s := scheduler.New()
+
+	var finished int32
+	err := s.AddTask(scheduler.Task{
+		Name:       "slow",
+		Cron:       "@every 1h",
+		RunAtStart: true,
+		Func: func() error {
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	s.Start()
+	time.Sleep(10 * time.Millisecond) // let the RunAtStart task begin
+	s.Stop()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&finished), "Stop should block until the in-flight task finishes")
+}
+
+func TestSchedulerRecordsLastError(t *testing.T) {
+	s := scheduler.New()
+
+	done := make(chan struct{})
+	err := s.AddTask(scheduler.Task{
+		Name:       "failing",
+		Cron:       "@every 1h",
+		RunAtStart: true,
+		Func: func() error {
+			defer close(done)
+			return assert.AnError
+		},
+	})
+	assert.NoError(t, err)
+
+	<-done
+	time.Sleep(10 * time.Millisecond)
+
+	status := s.Status("failing")
+	assert.Equal(t, assert.AnError, status.LastError)
+	assert.False(t, status.Running)
+}
@@ -0,0 +1,50 @@
+package jenkins
+
+import (
+	"fmt"
+
+	gojenkins "github.com/jenkins-x/golang-jenkins"
+)
+
+// DiscoverJobNames walks the full Jenkins job tree, recursing into Folder and
+// Multibranch Pipeline plugin containers via GetJob, and returns the full
+// name of every leaf job it finds. The returned names are in the raw
+// "/job/"-nested form produced by the Jenkins API and still need to be
+// normalised with ResolvePipelineName/PipelineNameSet
+func DiscoverJobNames(client gojenkins.JenkinsClient) ([]string, error) {
+	roots, err := client.GetJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Jenkins jobs: %v", err)
+	}
+
+	var names []string
+	for _, root := range roots {
+		jobNames, err := discoverJobNames(client, root.Name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, jobNames...)
+	}
+	return names, nil
+}
+
+func discoverJobNames(client gojenkins.JenkinsClient, fullName string) ([]string, error) {
+	job, err := client.GetJob(fullName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Jenkins job %s: %v", fullName, err)
+	}
+
+	if len(job.Jobs) == 0 {
+		return []string{fullName}, nil
+	}
+
+	var names []string
+	for _, child := range job.Jobs {
+		childNames, err := discoverJobNames(client, fullName+"/job/"+child.Name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, childNames...)
+	}
+	return names, nil
+}
@@ -0,0 +1,76 @@
+package jenkins_test
+
+import (
+	"fmt"
+	"testing"
+
+	gojenkins "github.com/jenkins-x/golang-jenkins"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jenkins-x/jx/pkg/jenkins"
+)
+
+// fakeJenkinsClient implements gojenkins.JenkinsClient over a fixed, in
+// memory job tree so DiscoverJobNames can be exercised without a real
+// Jenkins server. It embeds the interface so only the two methods
+// DiscoverJobNames actually calls, GetJobs and GetJob, need implementing
+type fakeJenkinsClient struct {
+	gojenkins.JenkinsClient
+	jobs map[string]gojenkins.Job
+}
+
+func (f *fakeJenkinsClient) GetJobs() ([]gojenkins.Job, error) {
+	return f.jobs[""].Jobs, nil
+}
+
+func (f *fakeJenkinsClient) GetJob(name string) (gojenkins.Job, error) {
+	job, ok := f.jobs[name]
+	if !ok {
+		return gojenkins.Job{}, fmt.Errorf("no such job %s", name)
+	}
+	return job, nil
+}
+
+func TestDiscoverJobNamesWalksNestedFolderTree(t *testing.T) {
+	client := &fakeJenkinsClient{
+		jobs: map[string]gojenkins.Job{
+			"": {Jobs: []gojenkins.Job{{Name: "team"}, {Name: "my-app"}}},
+			"team": {Name: "team", Jobs: []gojenkins.Job{
+				{Name: "service-a"},
+				{Name: "service-b"},
+			}},
+			"team/job/service-a": {Name: "service-a"},
+			"team/job/service-b": {Name: "service-b"},
+			"my-app":             {Name: "my-app"},
+		},
+	}
+
+	names, err := jenkins.DiscoverJobNames(client)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"team/job/service-a", "team/job/service-b", "my-app"}, names)
+}
+
+func TestDiscoverJobNamesResolveToPipelineNames(t *testing.T) {
+	client := &fakeJenkinsClient{
+		jobs: map[string]gojenkins.Job{
+			"": {Jobs: []gojenkins.Job{{Name: "my-org"}}},
+			"my-org": {Name: "my-org", Jobs: []gojenkins.Job{
+				{Name: "my-app"},
+			}},
+			"my-org/job/my-app": {Name: "my-app", Jobs: []gojenkins.Job{
+				{Name: "feature%2Ffoo"},
+				{Name: "PR-42"},
+			}},
+			"my-org/job/my-app/job/feature%2Ffoo": {Name: "feature%2Ffoo"},
+			"my-org/job/my-app/job/PR-42":         {Name: "PR-42"},
+		},
+	}
+
+	names, err := jenkins.DiscoverJobNames(client)
+	assert.NoError(t, err)
+
+	set := jenkins.PipelineNameSet(jenkins.DefaultResolvers, names)
+	assert.True(t, set["my-org/my-app/feature-foo"])
+	assert.True(t, set["my-org/my-app/PR-42"])
+	assert.Len(t, set, 2)
+}
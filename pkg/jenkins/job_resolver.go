@@ -0,0 +1,94 @@
+package jenkins
+
+import "strings"
+
+// JobResolver normalises a Jenkins job's full name, as discovered while
+// walking the job tree returned by the Jenkins API, into the Spec.Pipeline
+// form used by PipelineActivity resources. Different resolvers understand
+// different Jenkins job shapes: classic freestyle jobs, jobs nested under the
+// Folder plugin, and jobs created by the Multibranch Pipeline / GitHub
+// Organization Folder plugins
+type JobResolver interface {
+	// Resolve returns the normalised pipeline name for fullName, and false if
+	// this resolver does not recognise the shape of fullName
+	Resolve(fullName string) (pipeline string, ok bool)
+}
+
+// ClassicJobResolver resolves a plain, non-nested Jenkins job whose full name
+// is already the Spec.Pipeline name, e.g. "my-app"
+type ClassicJobResolver struct{}
+
+// Resolve implements JobResolver
+func (ClassicJobResolver) Resolve(fullName string) (string, bool) {
+	if strings.Contains(fullName, "/") {
+		return "", false
+	}
+	return fullName, true
+}
+
+// FolderJobResolver resolves jobs nested under the CloudBees Folder plugin,
+// whose full name looks like "team/job/my-app" for a job "my-app" inside
+// folder "team". It does not recognise names with a "%2F"-encoded branch
+// segment; those belong to MultiBranchJobResolver
+type FolderJobResolver struct{}
+
+// Resolve implements JobResolver
+func (FolderJobResolver) Resolve(fullName string) (string, bool) {
+	if !strings.Contains(fullName, "/job/") || strings.Contains(fullName, "%2F") {
+		return "", false
+	}
+	return strings.Replace(fullName, "/job/", "/", -1), true
+}
+
+// MultiBranchJobResolver resolves jobs created by the Multibranch Pipeline
+// and GitHub Organization Folder plugins, whose branch segment URL-encodes
+// '/' as "%2F" for branches such as "feature/foo", e.g.
+// "my-org/job/my-app/job/feature%2Ffoo". A multibranch job whose branch name
+// needs no encoding, e.g. "my-org/job/my-app/job/PR-42", is indistinguishable
+// from a plain folder job and is resolved by FolderJobResolver instead
+type MultiBranchJobResolver struct{}
+
+// Resolve implements JobResolver
+func (MultiBranchJobResolver) Resolve(fullName string) (string, bool) {
+	if !strings.Contains(fullName, "/job/") || !strings.Contains(fullName, "%2F") {
+		return "", false
+	}
+	name := strings.Replace(fullName, "/job/", "/", -1)
+	name = strings.Replace(name, "%2F", "-", -1)
+	return name, true
+}
+
+// DefaultResolvers is the ordered list of JobResolvers tried by
+// ResolvePipelineName. MultiBranchJobResolver and FolderJobResolver are
+// mutually exclusive on the presence of a "%2F"-encoded branch segment, so
+// their order relative to each other doesn't matter; ClassicJobResolver is
+// tried last since both of the above require "/job/" to be present
+var DefaultResolvers = []JobResolver{
+	MultiBranchJobResolver{},
+	FolderJobResolver{},
+	ClassicJobResolver{},
+}
+
+// ResolvePipelineName normalises fullName to its Spec.Pipeline form using the
+// given resolvers, trying each in turn until one recognises the job
+func ResolvePipelineName(resolvers []JobResolver, fullName string) (string, bool) {
+	for _, r := range resolvers {
+		if name, ok := r.Resolve(fullName); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// PipelineNameSet builds the set of Spec.Pipeline names for a slice of full
+// Jenkins job names, so that callers can do an O(1) membership test per
+// activity instead of a linear scan over every known job
+func PipelineNameSet(resolvers []JobResolver, fullNames []string) map[string]bool {
+	set := make(map[string]bool, len(fullNames))
+	for _, fullName := range fullNames {
+		if name, ok := ResolvePipelineName(resolvers, fullName); ok {
+			set[name] = true
+		}
+	}
+	return set
+}
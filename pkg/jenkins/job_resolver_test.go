@@ -0,0 +1,69 @@
+package jenkins_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jenkins-x/jx/pkg/jenkins"
+)
+
+func TestResolvePipelineNameClassic(t *testing.T) {
+	name, ok := jenkins.ResolvePipelineName(jenkins.DefaultResolvers, "my-app")
+	assert.True(t, ok)
+	assert.Equal(t, "my-app", name)
+}
+
+func TestResolvePipelineNameFolder(t *testing.T) {
+	name, ok := jenkins.ResolvePipelineName(jenkins.DefaultResolvers, "team/job/my-app")
+	assert.True(t, ok)
+	assert.Equal(t, "team/my-app", name)
+}
+
+func TestResolvePipelineNameNestedFolder(t *testing.T) {
+	name, ok := jenkins.ResolvePipelineName(jenkins.DefaultResolvers, "team/job/sub-team/job/my-app")
+	assert.True(t, ok)
+	assert.Equal(t, "team/sub-team/my-app", name)
+}
+
+func TestResolvePipelineNameMultiBranch(t *testing.T) {
+	name, ok := jenkins.ResolvePipelineName(jenkins.DefaultResolvers, "my-org/job/my-app/job/PR-42")
+	assert.True(t, ok)
+	assert.Equal(t, "my-org/my-app/PR-42", name)
+}
+
+func TestResolvePipelineNameMultiBranchEncodedBranch(t *testing.T) {
+	name, ok := jenkins.ResolvePipelineName(jenkins.DefaultResolvers, "my-org/job/my-app/job/feature%2Ffoo")
+	assert.True(t, ok)
+	assert.Equal(t, "my-org/my-app/feature-foo", name)
+}
+
+func TestFolderJobResolverIsReachableForPlainMultiBranchNames(t *testing.T) {
+	// "my-org/job/my-app/job/PR-42" has no "%2F"-encoded branch segment, so
+	// MultiBranchJobResolver must decline it and FolderJobResolver must be
+	// the one that actually resolves it
+	_, ok := jenkins.MultiBranchJobResolver{}.Resolve("my-org/job/my-app/job/PR-42")
+	assert.False(t, ok, "MultiBranchJobResolver should only resolve names with an encoded %%2F branch segment")
+
+	name, ok := jenkins.FolderJobResolver{}.Resolve("my-org/job/my-app/job/PR-42")
+	assert.True(t, ok)
+	assert.Equal(t, "my-org/my-app/PR-42", name)
+}
+
+func TestMultiBranchJobResolverDeclinesPlainFolderNames(t *testing.T) {
+	_, ok := jenkins.MultiBranchJobResolver{}.Resolve("team/job/my-app")
+	assert.False(t, ok)
+}
+
+func TestPipelineNameSet(t *testing.T) {
+	set := jenkins.PipelineNameSet(jenkins.DefaultResolvers, []string{
+		"my-app",
+		"team/job/other-app",
+		"my-org/job/my-app/job/master",
+	})
+
+	assert.True(t, set["my-app"])
+	assert.True(t, set["team/other-app"])
+	assert.True(t, set["my-org/my-app/master"])
+	assert.Len(t, set, 3)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jenkins-x/jx/pkg/gc/scheduler"
+	"github.com/jenkins-x/jx/pkg/jx/cmd/opts"
+	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
+	"github.com/jenkins-x/jx/pkg/log"
+)
+
+// ControllerGCOptions holds the options for the `jx controller gc` command
+type ControllerGCOptions struct {
+	*opts.CommonOptions
+}
+
+var (
+	controllerGCLong = templates.LongDesc(`
+		Runs the Jenkins X garbage collection tasks on the cron schedules configured by the %s ConfigMap in
+		the development namespace, instead of relying on an externally wired up CronJob to invoke 'jx gc'
+		commands.
+
+		This controller is intentionally scoped to the "activities" task type only for now; scheduling gc
+		previews, gc helm or gc pods, and the Helm chart/RBAC changes needed to deploy this controller with
+		delete rights on pipelineactivities, are tracked as follow-up work rather than being half-wired here.
+		A schedule referencing an unsupported type is rejected by LoadConfigMap at startup, not discovered
+		later at its first cron fire. Each task's last run time and error are recorded both in-process and
+		back into the ConfigMap's status.yaml entry, so they can be inspected with kubectl without needing
+		access to the running controller.
+`)
+
+	controllerGCExample = templates.Examples(`
+		jx controller gc
+`)
+)
+
+// NewCmdControllerGC creates the command object for "controller gc"
+func NewCmdControllerGC(commonOpts *opts.CommonOptions) *cobra.Command {
+	options := &ControllerGCOptions{
+		CommonOptions: commonOpts,
+	}
+
+	cmd := &cobra.Command{
+		Use:     "gc",
+		Short:   "Runs the garbage collection controller which enforces the cluster's gc schedule",
+		Long:    fmt.Sprintf(controllerGCLong, scheduler.ConfigMapName),
+		Example: controllerGCExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			options.Cmd = cmd
+			options.Args = args
+			err := options.Run()
+			CheckErr(err)
+		},
+	}
+	return cmd
+}
+
+// Run implements this command
+func (o *ControllerGCOptions) Run() error {
+	kubeClient, ns, err := o.KubeClientAndDevNamespace()
+	if err != nil {
+		return err
+	}
+
+	config, err := scheduler.LoadConfigMap(kubeClient, ns)
+	if err != nil {
+		return err
+	}
+
+	s := scheduler.New()
+	for _, task := range config.Tasks {
+		task := task
+		err := s.AddTask(scheduler.Task{
+			Name:       task.Name,
+			Cron:       task.Cron,
+			RunAtStart: task.RunAtStart,
+			Func: func() error {
+				return o.runTask(task)
+			},
+			OnStatus: func(status scheduler.Status) {
+				if err := scheduler.SaveTaskStatus(kubeClient, ns, task.Name, status.LastRunTime, status.LastError); err != nil {
+					log.Warnf("controller gc: failed to persist status for task %s: %v\n", task.Name, err)
+				}
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Infof("controller gc: scheduled %d tasks from the %s ConfigMap\n", len(config.Tasks), scheduler.ConfigMapName)
+	s.Start()
+
+	// block forever, the cron scheduler runs tasks on its own goroutines
+	select {}
+}
+
+// runTask dispatches a single scheduled task to the matching `jx gc` implementation
+func (o *ControllerGCOptions) runTask(task scheduler.TaskConfig) error {
+	switch task.Type {
+	case "activities":
+		gc := &GCActivitiesOptions{
+			CommonOptions:        o.CommonOptions,
+			RevisionHistoryLimit: task.RevisionHistoryLimit,
+			PullRequestHours:     task.PullRequestHours,
+			KeepRunning:          true,
+		}
+		return gc.Run()
+	default:
+		return fmt.Errorf("gc task %s has unsupported type %q, only \"activities\" is currently wired up to the controller", task.Name, task.Type)
+	}
+}
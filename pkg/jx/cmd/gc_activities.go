@@ -5,25 +5,78 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	gojenkins "github.com/jenkins-x/golang-jenkins"
+	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
 
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx/pkg/jenkins"
 	"github.com/jenkins-x/jx/pkg/jx/cmd/opts"
 	"github.com/jenkins-x/jx/pkg/jx/cmd/templates"
 	"github.com/jenkins-x/jx/pkg/log"
+	"github.com/jenkins-x/jx/pkg/metrics"
+	"github.com/jenkins-x/jx/pkg/prow"
 )
 
+// progressLogInterval controls how often the deletion worker pool logs
+// "deleted N/M activities" while it works through a large backlog
+const progressLogInterval = 50
+
+// defaultInclude is the default value of --include. Only "activities" is
+// included by default: the prowjobs/pipelineruns/taskruns kinds delete
+// resources this command never touched before they existed, so they are
+// opt-in
+const defaultInclude = "activities"
+
+// defaultKeepSince is the default value of --keep-since: a conservative
+// window that only catches Prow/Tekton resources well clear of any running
+// release, chosen independently of --pull-request-hours since that flag
+// governs PipelineActivity retention, not Prow/Tekton retention
+const defaultKeepSince = 7 * 24 * time.Hour
+
 // GetOptions is the start of the data required to perform the operation.  As new fields are added, add them here instead of
 // referencing the cmd.Flags()
 type GCActivitiesOptions struct {
 	*opts.CommonOptions
 
-	RevisionHistoryLimit int
-	PullRequestHours     int
-	jclient              gojenkins.JenkinsClient
+	RevisionHistoryLimit       int
+	RevisionHistoryLimitFailed int
+	PullRequestHours           int
+	KeepSuccessfulHours        int
+	KeepFailedHours            int
+	KeepRunning                bool
+	OlderThan                  string
+	KeepSince                  string
+	DryRun                     bool
+	Concurrency                int
+	Include                    string
+
+	olderThan time.Duration
+	keepSince time.Duration
+	jclient   gojenkins.JenkinsClient
+}
+
+// activityGroupKey groups activities by pipeline and branch so that retention
+// rules can be applied per bucket, e.g. keep more successful master builds
+// than failed feature branch builds
+type activityGroupKey struct {
+	Pipeline string
+	Branch   string
+	Failed   bool
+}
+
+// deletionCandidate is an activity selected for deletion along with a short
+// human readable reason, used both for the deletion loop and for --dry-run
+// reporting
+type deletionCandidate struct {
+	Name   string
+	Reason string
 }
 
 var (
@@ -56,18 +109,84 @@ func NewCmdGCActivities(commonOpts *opts.CommonOptions) *cobra.Command {
 			CheckErr(err)
 		},
 	}
-	cmd.Flags().IntVarP(&options.RevisionHistoryLimit, "revision-history-limit", "l", 5, "Minimum number of Activities per application to keep")
+	cmd.Flags().IntVarP(&options.RevisionHistoryLimit, "revision-history-limit", "l", 5, "Minimum number of Activities per pipeline/branch to keep, counted separately for successful and failed Activities")
+	cmd.Flags().IntVar(&options.RevisionHistoryLimitFailed, "revision-history-limit-failed", 0, "Minimum number of failed Activities per pipeline/branch to keep, e.g. to keep fewer failed feature branch builds than successful master builds; 0 uses --revision-history-limit for failed activities too")
 	cmd.Flags().IntVarP(&options.PullRequestHours, "pull-request-hours", "p", 48, "Number of hours to keep pull request activities for")
+	cmd.Flags().IntVar(&options.KeepSuccessfulHours, "keep-successful-hours", 0, "Number of hours to keep successful activities beyond the revision history limit, 0 to disable")
+	cmd.Flags().IntVar(&options.KeepFailedHours, "keep-failed-hours", 0, "Number of hours to keep failed activities beyond the revision history limit, 0 to disable")
+	cmd.Flags().BoolVar(&options.KeepRunning, "keep-running", true, "Never delete activities which are still running, mirroring 'pipelinerun delete --ignore-running'")
+	cmd.Flags().StringVar(&options.OlderThan, "older-than", "", "Garbage collect any completed activity older than this duration (e.g. 168h) regardless of the revision history limit")
+	cmd.Flags().StringVar(&options.KeepSince, "keep-since", defaultKeepSince.String(), "Garbage collect completed ProwJobs/PipelineRuns/TaskRuns older than this duration (e.g. 168h), independently of --pull-request-hours")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Print which activities would be deleted and why, without deleting them")
+	cmd.Flags().IntVar(&options.Concurrency, "concurrency", 8, "The number of activities to delete in parallel")
+	cmd.Flags().StringVar(&options.Include, "include", defaultInclude, "Comma separated list of resource kinds to garbage collect: activities, prowjobs, pipelineruns, taskruns. Only \"activities\" is included by default")
 	return cmd
 }
 
+// includeSet parses the --include flag into a lookup set
+func (o *GCActivitiesOptions) includeSet() map[string]bool {
+	include := o.Include
+	if include == "" {
+		include = defaultInclude
+	}
+	set := map[string]bool{}
+	for _, kind := range strings.Split(include, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind != "" {
+			set[kind] = true
+		}
+	}
+	return set
+}
+
 // Run implements this command
 func (o *GCActivitiesOptions) Run() error {
+	if o.OlderThan != "" {
+		d, err := time.ParseDuration(o.OlderThan)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than duration %q: %v", o.OlderThan, err)
+		}
+		o.olderThan = d
+	}
+
+	o.keepSince = defaultKeepSince
+	if o.KeepSince != "" {
+		d, err := time.ParseDuration(o.KeepSince)
+		if err != nil {
+			return fmt.Errorf("invalid --keep-since duration %q: %v", o.KeepSince, err)
+		}
+		o.keepSince = d
+	}
+
 	client, currentNs, err := o.JXClientAndDevNamespace()
 	if err != nil {
 		return err
 	}
 
+	prowEnabled, err := o.IsProw()
+	if err != nil {
+		return err
+	}
+
+	include := o.includeSet()
+
+	if include["activities"] {
+		if err := o.gcActivities(client, currentNs, prowEnabled); err != nil {
+			return err
+		}
+	}
+
+	if prowEnabled && (include["prowjobs"] || include["pipelineruns"] || include["taskruns"]) {
+		if err := o.gcProwResources(currentNs, include); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gcActivities garbage collects the JX PipelineActivity CRD
+func (o *GCActivitiesOptions) gcActivities(client versioned.Interface, currentNs string, prowEnabled bool) error {
 	// cannot use field selectors like `spec.kind=Preview` on CRDs so list all environments
 	activities, err := client.JenkinsV1().PipelineActivities(currentNs).List(metav1.ListOptions{})
 	if err != nil {
@@ -81,90 +200,227 @@ func (o *GCActivitiesOptions) Run() error {
 		return nil
 	}
 
-	prowEnabled, err := o.IsProw()
-	if err != nil {
-		return err
-	}
-
-	var jobNames []string
+	pipelineNames := map[string]bool{}
 	if !prowEnabled {
 		o.jclient, err = o.JenkinsClient()
 		if err != nil {
 			return err
 		}
 
-		jobs, err := o.jclient.GetJobs()
+		jobNames, err := jenkins.DiscoverJobNames(o.jclient)
 		if err != nil {
 			return err
 		}
-		for _, j := range jobs {
-			err = o.GetAllPipelineJobNames(o.jclient, &jobNames, j.Name)
-			if err != nil {
-				return err
-			}
+		pipelineNames = jenkins.PipelineNameSet(jenkins.DefaultResolvers, jobNames)
+	}
+
+	toDelete := o.selectActivitiesForDeletion(activities.Items, pipelineNames, prowEnabled)
+
+	if o.DryRun {
+		for _, candidate := range toDelete {
+			log.Infof("gc: would delete activity %s: %s\n", candidate.Name, candidate.Reason)
 		}
+		return nil
 	}
 
-	activityBuilds := make(map[string][]int)
+	return o.deleteActivities(client, currentNs, toDelete)
+}
 
-	for _, a := range activities.Items {
-		// if the activity is a PR and has completed over a week ago lets GC it
+// gcProwResources garbage collects the ProwJob and Tekton PipelineRun/TaskRun
+// resources Prow creates, which are otherwise left behind once their JX
+// PipelineActivity has already been cleaned up. Retention is governed by
+// --keep-since, not --pull-request-hours, since these resources cover every
+// build Prow runs, not just pull requests; --dry-run is honoured by
+// prow.GC the same way it is for activities
+func (o *GCActivitiesOptions) gcProwResources(currentNs string, include map[string]bool) error {
+	prowClient, err := o.ProwJobClient()
+	if err != nil {
+		return err
+	}
+	tektonClient, err := o.TektonClient()
+	if err != nil {
+		return err
+	}
+
+	return prow.GC(prowClient, tektonClient, currentNs, prow.GCOptions{
+		KeepSince:           o.keepSince,
+		IgnoreRunning:       !o.KeepRunning,
+		IncludeProwJobs:     include["prowjobs"],
+		IncludePipelineRuns: include["pipelineruns"],
+		IncludeTaskRuns:     include["taskruns"],
+		DryRun:              o.DryRun,
+	})
+}
+
+// deleteActivities deletes the given candidates using a bounded worker pool
+// so that a single slow or failing delete cannot stall the whole run. Errors
+// are aggregated rather than aborting on the first failure, so a transient
+// API server hiccup leaves as few activities behind as possible
+func (o *GCActivitiesOptions) deleteActivities(client versioned.Interface, ns string, candidates []deletionCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.GC.ActivitiesDurationSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	concurrency := o.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(float32(concurrency), concurrency)
+
+	work := make(chan deletionCandidate)
+	var wg sync.WaitGroup
+
+	var mu sync.Mutex
+	var succeeded, failed int
+	var errs *multierror.Error
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for candidate := range work {
+				limiter.Accept()
+				delErr := client.JenkinsV1().PipelineActivities(ns).Delete(candidate.Name, metav1.NewDeleteOptions(0))
+
+				mu.Lock()
+				if delErr != nil {
+					metrics.GC.ActivitiesDeleteErrorTotal.Inc()
+					errs = multierror.Append(errs, fmt.Errorf("failed to delete activity %s: %v", candidate.Name, delErr))
+					failed++
+				} else {
+					metrics.GC.ActivitiesDeletedTotal.Inc()
+					succeeded++
+				}
+				done := succeeded + failed
+				if done%progressLogInterval == 0 {
+					log.Infof("gc: deleted %d/%d activities\n", done, len(candidates))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, candidate := range candidates {
+		work <- candidate
+	}
+	close(work)
+	wg.Wait()
+
+	log.Infof("gc: deleted %d/%d activities\n", succeeded, len(candidates))
+
+	if errs != nil {
+		return fmt.Errorf("gc: deleted %d/%d activities, %d failed: %v", succeeded, len(candidates), failed, errs)
+	}
+	return nil
+}
+
+// selectActivitiesForDeletion applies the orphaned-job, pull-request-hours,
+// keep-running, older-than and per-(pipeline,branch,status) revision history
+// rules and returns the activities that should be deleted, and why
+func (o *GCActivitiesOptions) selectActivitiesForDeletion(activities []v1.PipelineActivity, pipelineNames map[string]bool, prowEnabled bool) []deletionCandidate {
+	var toDelete []deletionCandidate
+	groups := make(map[activityGroupKey][]v1.PipelineActivity)
+
+	for _, a := range activities {
+		a := a
+
+		// if the activity is a PR and has completed over the configured window ago lets GC it
 		if strings.Contains(a.Name, "-pr-") {
 			if a.Spec.CompletedTimestamp != nil && a.Spec.CompletedTimestamp.Add(time.Duration(o.PullRequestHours)*time.Hour).Before(time.Now()) {
-				err = client.JenkinsV1().PipelineActivities(currentNs).Delete(a.Name, metav1.NewDeleteOptions(0))
-				if err != nil {
-					return err
-				}
+				toDelete = append(toDelete, deletionCandidate{a.Name, "pull request activity expired"})
 				continue
 			}
 		}
 
-		if !prowEnabled {
-			// if activity has no job in Jenkins delete it
-			matched := false
-			for _, j := range jobNames {
-				if a.Spec.Pipeline == j {
-					matched = true
-					break
-				}
-			}
-			if !matched {
-				err = client.JenkinsV1().PipelineActivities(currentNs).Delete(a.Name, metav1.NewDeleteOptions(0))
-				if err != nil {
-					return err
-				}
+		if !prowEnabled && !pipelineNames[a.Spec.Pipeline] {
+			// no matching job in Jenkins any more so delete it
+			toDelete = append(toDelete, deletionCandidate{a.Name, "no matching Jenkins job"})
+			continue
+		}
+
+		if o.isRunning(&a) {
+			if o.KeepRunning {
+				continue
 			}
+		} else if o.olderThan > 0 && a.Spec.CompletedTimestamp != nil && a.Spec.CompletedTimestamp.Add(o.olderThan).Before(time.Now()) {
+			toDelete = append(toDelete, deletionCandidate{a.Name, fmt.Sprintf("older than --older-than %s", o.OlderThan)})
+			continue
 		}
 
-		buildNumber, err := strconv.Atoi(a.Spec.Build)
-		if err != nil {
-			return err
+		key := activityGroupKey{
+			Pipeline: a.Spec.Pipeline,
+			Branch:   a.Spec.GitBranch,
+			Failed:   o.isFailed(&a),
+		}
+		groups[key] = append(groups[key], a)
+	}
+
+	for key, group := range groups {
+		limit := o.revisionHistoryLimitFor(key)
+		if len(group) <= limit && o.KeepSuccessfulHours == 0 && o.KeepFailedHours == 0 {
+			if o.Verbose {
+				log.Infof("gc: keeping all %d activities for pipeline %s branch %s, below the revision history limit\n", len(group), key.Pipeline, key.Branch)
+			}
+			continue
 		}
+		toDelete = append(toDelete, o.selectGroupForDeletion(key, group, limit)...)
+	}
+
+	return toDelete
+}
 
-		// collect all activities for a pipeline
-		activityBuilds[a.Spec.Pipeline] = append(activityBuilds[a.Spec.Pipeline], buildNumber)
+// revisionHistoryLimitFor returns the count-based retention limit for a
+// (pipeline, branch, outcome) bucket, letting failed buckets be pruned harder
+// than successful ones via --revision-history-limit-failed, e.g. to keep 10
+// successful master builds but only 2 failed feature branch builds
+func (o *GCActivitiesOptions) revisionHistoryLimitFor(key activityGroupKey) int {
+	if key.Failed && o.RevisionHistoryLimitFailed > 0 {
+		return o.RevisionHistoryLimitFailed
 	}
+	return o.RevisionHistoryLimit
+}
 
-	for pipeline, builds := range activityBuilds {
+// selectGroupForDeletion applies the revision history limit and the
+// status-aware hour based retention to a single (pipeline, branch, outcome)
+// bucket of activities
+func (o *GCActivitiesOptions) selectGroupForDeletion(key activityGroupKey, group []v1.PipelineActivity, limit int) []deletionCandidate {
+	sort.Slice(group, func(i, j int) bool {
+		bi, _ := strconv.Atoi(group[i].Spec.Build)
+		bj, _ := strconv.Atoi(group[j].Spec.Build)
+		return bi < bj
+	})
 
-		sort.Ints(builds)
+	keepHours := o.KeepSuccessfulHours
+	if key.Failed {
+		keepHours = o.KeepFailedHours
+	}
 
-		// iterate over the build numbers and delete any while the activity is under the RevisionHistoryLimit
-		i := 0
-		for i < len(builds)-o.RevisionHistoryLimit {
-			activityName := fmt.Sprintf("%s-%v", pipeline, builds[i])
-			activityName = strings.Replace(activityName, "/", "-", -1)
-			activityName = strings.Replace(activityName, "_", "-", -1)
-			activityName = strings.ToLower(activityName)
+	var toDelete []deletionCandidate
+	for i := 0; i < len(group)-limit; i++ {
+		a := group[i]
 
-			err = client.JenkinsV1().PipelineActivities(currentNs).Delete(activityName, metav1.NewDeleteOptions(0))
-			if err != nil {
-				return fmt.Errorf("failed to delete activity %s: %v\n", activityName, err)
+		if keepHours > 0 {
+			if a.Spec.CompletedTimestamp == nil || !a.Spec.CompletedTimestamp.Add(time.Duration(keepHours)*time.Hour).Before(time.Now()) {
+				// still inside the status specific retention window so keep it
+				continue
 			}
-
-			i++
 		}
+
+		toDelete = append(toDelete, deletionCandidate{a.Name, "over revision history limit"})
 	}
 
-	return nil
+	return toDelete
+}
+
+func (o *GCActivitiesOptions) isRunning(a *v1.PipelineActivity) bool {
+	return a.Spec.CompletedTimestamp == nil
+}
+
+func (o *GCActivitiesOptions) isFailed(a *v1.PipelineActivity) bool {
+	return a.Spec.Status == v1.ActivityStatusTypeFailed || a.Spec.Status == v1.ActivityStatusTypeError || a.Spec.Status == v1.ActivityStatusTypeAborted
 }
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	k8stesting "k8s.io/client-go/testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/client/clientset/versioned/fake"
+)
+
+func createTestActivity(pipeline, branch, build string, status v1.ActivityStatusType, completed *metav1.Time) v1.PipelineActivity {
+	name := pipeline + "-" + build
+	return v1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1.PipelineActivitySpec{
+			Pipeline:           pipeline,
+			GitBranch:          branch,
+			Build:              build,
+			Status:             status,
+			CompletedTimestamp: completed,
+		},
+	}
+}
+
+func deletionNames(candidates []deletionCandidate) []string {
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func TestGCActivitiesKeepsRevisionHistoryLimitPerBranch(t *testing.T) {
+	completed := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	activities := []v1.PipelineActivity{
+		createTestActivity("myapp/master", "master", "1", v1.ActivityStatusTypeSucceeded, &completed),
+		createTestActivity("myapp/master", "master", "2", v1.ActivityStatusTypeSucceeded, &completed),
+		createTestActivity("myapp/master", "master", "3", v1.ActivityStatusTypeSucceeded, &completed),
+		createTestActivity("myapp/feature-x", "feature-x", "1", v1.ActivityStatusTypeFailed, &completed),
+		createTestActivity("myapp/feature-x", "feature-x", "2", v1.ActivityStatusTypeFailed, &completed),
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 1}
+	toDelete := o.selectActivitiesForDeletion(activities, nil, true)
+
+	assert.ElementsMatch(t, []string{"myapp/master-1", "myapp/master-2", "myapp/feature-x-1"}, deletionNames(toDelete))
+}
+
+func TestGCActivitiesRevisionHistoryLimitFailedOverridesCountForFailedBuckets(t *testing.T) {
+	completed := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	var activities []v1.PipelineActivity
+	for i := 1; i <= 10; i++ {
+		activities = append(activities, createTestActivity("myapp/master", "master", fmt.Sprintf("%d", i), v1.ActivityStatusTypeSucceeded, &completed))
+	}
+	for i := 1; i <= 5; i++ {
+		activities = append(activities, createTestActivity("myapp/feature-x", "feature-x", fmt.Sprintf("%d", i), v1.ActivityStatusTypeFailed, &completed))
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 10, RevisionHistoryLimitFailed: 2}
+	toDelete := o.selectActivitiesForDeletion(activities, nil, true)
+
+	assert.ElementsMatch(t, []string{"myapp/feature-x-1", "myapp/feature-x-2", "myapp/feature-x-3"}, deletionNames(toDelete),
+		"10 successful master builds should all be kept, but only the 2 newest failed feature branch builds should survive")
+}
+
+func TestGCActivitiesKeepFailedHoursOverridesRevisionHistoryLimit(t *testing.T) {
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	activities := []v1.PipelineActivity{
+		createTestActivity("myapp/feature-x", "feature-x", "1", v1.ActivityStatusTypeFailed, &recent),
+		createTestActivity("myapp/feature-x", "feature-x", "2", v1.ActivityStatusTypeFailed, &recent),
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 0, KeepFailedHours: 24}
+	toDelete := o.selectActivitiesForDeletion(activities, nil, true)
+
+	assert.Empty(t, toDelete, "recent failed activities should be kept for keep-failed-hours even under the revision history limit")
+}
+
+func TestGCActivitiesOlderThanDeletesRegardlessOfCount(t *testing.T) {
+	old := metav1.NewTime(time.Now().Add(-200 * time.Hour))
+
+	activities := []v1.PipelineActivity{
+		createTestActivity("myapp/master", "master", "1", v1.ActivityStatusTypeSucceeded, &old),
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 5, olderThan: 168 * time.Hour, OlderThan: "168h"}
+	toDelete := o.selectActivitiesForDeletion(activities, nil, true)
+
+	assert.Equal(t, []string{"myapp/master-1"}, deletionNames(toDelete))
+}
+
+func TestGCActivitiesKeepRunningSkipsInProgressActivities(t *testing.T) {
+	activities := []v1.PipelineActivity{
+		createTestActivity("myapp/master", "master", "1", v1.ActivityStatusTypeRunning, nil),
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 0, KeepRunning: true}
+	toDelete := o.selectActivitiesForDeletion(activities, nil, true)
+
+	assert.Empty(t, toDelete, "a running activity should never be deleted when --keep-running is set")
+}
+
+func TestGCActivitiesDeletesOrphanedJenkinsJobs(t *testing.T) {
+	completed := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	activities := []v1.PipelineActivity{
+		createTestActivity("myapp/master", "master", "1", v1.ActivityStatusTypeSucceeded, &completed),
+	}
+
+	o := &GCActivitiesOptions{RevisionHistoryLimit: 5}
+	toDelete := o.selectActivitiesForDeletion(activities, map[string]bool{"otherapp/master": true}, false)
+
+	assert.Equal(t, []string{"myapp/master-1"}, deletionNames(toDelete))
+	assert.Equal(t, "no matching Jenkins job", toDelete[0].Reason)
+}
+
+func TestGCActivitiesIncludeDefaultsToActivitiesOnly(t *testing.T) {
+	o := &GCActivitiesOptions{}
+	include := o.includeSet()
+
+	assert.True(t, include["activities"])
+	assert.False(t, include["prowjobs"], "prowjobs must be opt-in via --include, not on by default")
+	assert.False(t, include["pipelineruns"], "pipelineruns must be opt-in via --include, not on by default")
+	assert.False(t, include["taskruns"], "taskruns must be opt-in via --include, not on by default")
+}
+
+func TestGCActivitiesDeleteActivitiesAggregatesPartialFailures(t *testing.T) {
+	var candidates []deletionCandidate
+	activities := make([]v1.PipelineActivity, 0, 10)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("myapp-master-%d", i)
+		activities = append(activities, v1.PipelineActivity{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		candidates = append(candidates, deletionCandidate{Name: name, Reason: "test"})
+	}
+
+	client := fake.NewSimpleClientset()
+	for _, a := range activities {
+		_, err := client.JenkinsV1().PipelineActivities("jx").Create(&a)
+		assert.NoError(t, err)
+	}
+
+	client.PrependReactor("delete", "pipelineactivities", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.DeleteAction).GetName()
+		if name == "myapp-master-3" || name == "myapp-master-7" {
+			return true, nil, fmt.Errorf("simulated api server hiccup")
+		}
+		return false, nil, nil
+	})
+
+	o := &GCActivitiesOptions{Concurrency: 4}
+	err := o.deleteActivities(client, "jx", candidates)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "deleted 8/10 activities")
+	assert.Contains(t, err.Error(), "2 failed")
+
+	list, err := client.JenkinsV1().PipelineActivities("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 2, "the two activities whose delete failed should still be present")
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// GC exposes the Prometheus metrics emitted by the `jx gc` commands so that
+// operators running gc on a schedule can alert on regressions
+var GC = struct {
+	ActivitiesDeletedTotal     prometheus.Counter
+	ActivitiesDeleteErrorTotal prometheus.Counter
+	ActivitiesDurationSeconds  prometheus.Histogram
+}{
+	ActivitiesDeletedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jx_gc_activities_deleted_total",
+		Help: "The total number of PipelineActivity resources deleted by jx gc activities",
+	}),
+	ActivitiesDeleteErrorTotal: prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jx_gc_activities_delete_errors_total",
+		Help: "The total number of PipelineActivity deletions that failed during jx gc activities",
+	}),
+	ActivitiesDurationSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "jx_gc_activities_duration_seconds",
+		Help: "The time taken for a jx gc activities run to delete all of its selected activities",
+	}),
+}
+
+func init() {
+	prometheus.MustRegister(
+		GC.ActivitiesDeletedTotal,
+		GC.ActivitiesDeleteErrorTotal,
+		GC.ActivitiesDurationSeconds,
+	)
+}
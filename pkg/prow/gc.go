@@ -0,0 +1,141 @@
+package prow
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowclient "k8s.io/test-infra/prow/client/clientset/versioned"
+
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+
+	"github.com/jenkins-x/jx/pkg/log"
+)
+
+// prowJobIDLabel is set by Prow's Tekton plumbing on every PipelineRun it creates for a ProwJob
+const prowJobIDLabel = "prow.k8s.io/id"
+
+// pipelineRunLabel is set by Tekton on every TaskRun it creates for a PipelineRun
+const pipelineRunLabel = "tekton.dev/pipelineRun"
+
+// GCOptions configures a single garbage collection sweep of the Prow managed
+// resources left behind once a JX PipelineActivity has already been cleaned up:
+// ProwJobs and the Tekton PipelineRuns/TaskRuns they created
+type GCOptions struct {
+	// KeepSince mirrors the Tekton CLI's `pipelinerun delete --keep-since`: any
+	// ProwJob that completed longer ago than this is deleted, together with the
+	// PipelineRuns/TaskRuns created for it, whose own ages are not checked
+	KeepSince time.Duration
+	// IgnoreRunning deletes resources whose CompletionTime is nil (still
+	// running) instead of skipping them
+	IgnoreRunning bool
+	// IncludeProwJobs, IncludePipelineRuns and IncludeTaskRuns opt in to each
+	// resource kind independently, mirroring the `--include` flag on `jx gc activities`
+	IncludeProwJobs     bool
+	IncludePipelineRuns bool
+	IncludeTaskRuns     bool
+	// DryRun logs what would be deleted instead of deleting it, mirroring
+	// `jx gc activities --dry-run`
+	DryRun bool
+}
+
+// GC deletes, for each kind opted in via opts, the ProwJobs and the Tekton
+// PipelineRuns and TaskRuns they created, once the ProwJob is older than
+// KeepSince (or still running, when IgnoreRunning is set). Pods created for
+// a TaskRun are not deleted directly, they are cascaded by Kubernetes' own
+// owner reference garbage collection once the TaskRun is deleted
+func GC(prowClient prowclient.Interface, tektonClient tektonclient.Interface, ns string, opts GCOptions) error {
+	if !opts.IncludeProwJobs && !opts.IncludePipelineRuns && !opts.IncludeTaskRuns {
+		return nil
+	}
+
+	jobs, err := prowClient.ProwV1().ProwJobs(ns).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list ProwJobs in namespace %s: %v", ns, err)
+	}
+
+	for _, job := range jobs.Items {
+		job := job
+
+		if job.Status.CompletionTime == nil {
+			if !opts.IgnoreRunning {
+				continue
+			}
+		} else if !job.Status.CompletionTime.Add(opts.KeepSince).Before(time.Now()) {
+			continue
+		}
+
+		if opts.IncludePipelineRuns || opts.IncludeTaskRuns {
+			if err := gcPipelineRunsForJob(tektonClient, ns, job.Name, opts); err != nil {
+				return err
+			}
+		}
+
+		if opts.IncludeProwJobs {
+			if opts.DryRun {
+				log.Infof("gc: would delete ProwJob %s\n", job.Name)
+			} else {
+				if err := prowClient.ProwV1().ProwJobs(ns).Delete(job.Name, metav1.NewDeleteOptions(0)); err != nil {
+					return fmt.Errorf("failed to delete ProwJob %s: %v", job.Name, err)
+				}
+				log.Infof("gc: deleted ProwJob %s\n", job.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func gcPipelineRunsForJob(tektonClient tektonclient.Interface, ns, prowJobName string, opts GCOptions) error {
+	runs, err := tektonClient.TektonV1alpha1().PipelineRuns(ns).List(metav1.ListOptions{
+		LabelSelector: prowJobIDLabel + "=" + prowJobName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list PipelineRuns for ProwJob %s: %v", prowJobName, err)
+	}
+
+	for _, run := range runs.Items {
+		run := run
+
+		if opts.IncludeTaskRuns {
+			if err := gcTaskRunsForPipelineRun(tektonClient, ns, run.Name, opts.DryRun); err != nil {
+				return err
+			}
+		}
+
+		if opts.IncludePipelineRuns {
+			if opts.DryRun {
+				log.Infof("gc: would delete PipelineRun %s\n", run.Name)
+			} else {
+				if err := tektonClient.TektonV1alpha1().PipelineRuns(ns).Delete(run.Name, metav1.NewDeleteOptions(0)); err != nil {
+					return fmt.Errorf("failed to delete PipelineRun %s: %v", run.Name, err)
+				}
+				log.Infof("gc: deleted PipelineRun %s\n", run.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func gcTaskRunsForPipelineRun(tektonClient tektonclient.Interface, ns, pipelineRunName string, dryRun bool) error {
+	taskRuns, err := tektonClient.TektonV1alpha1().TaskRuns(ns).List(metav1.ListOptions{
+		LabelSelector: pipelineRunLabel + "=" + pipelineRunName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list TaskRuns for PipelineRun %s: %v", pipelineRunName, err)
+	}
+
+	for _, tr := range taskRuns.Items {
+		if dryRun {
+			log.Infof("gc: would delete TaskRun %s\n", tr.Name)
+			continue
+		}
+		if err := tektonClient.TektonV1alpha1().TaskRuns(ns).Delete(tr.Name, metav1.NewDeleteOptions(0)); err != nil {
+			return fmt.Errorf("failed to delete TaskRun %s: %v", tr.Name, err)
+		}
+		log.Infof("gc: deleted TaskRun %s\n", tr.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package prow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowjobsv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	prowfake "k8s.io/test-infra/prow/client/clientset/versioned/fake"
+
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+
+	"github.com/jenkins-x/jx/pkg/prow"
+)
+
+func TestGCDeletesOldCompletedProwJobsAndCascadesToTektonResources(t *testing.T) {
+	old := metav1.NewTime(time.Now().Add(-48 * time.Hour))
+
+	prowClient := prowfake.NewSimpleClientset(&prowjobsv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-1", Namespace: "jx"},
+		Status:     prowjobsv1.ProwJobStatus{CompletionTime: &old},
+	})
+
+	tektonClient := tektonfake.NewSimpleClientset(
+		&pipelinev1alpha1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "pr-1", Namespace: "jx", Labels: map[string]string{"prow.k8s.io/id": "job-1"}},
+		},
+		&pipelinev1alpha1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "tr-1", Namespace: "jx", Labels: map[string]string{"tekton.dev/pipelineRun": "pr-1"}},
+		},
+	)
+
+	err := prow.GC(prowClient, tektonClient, "jx", prow.GCOptions{
+		KeepSince:           24 * time.Hour,
+		IncludeProwJobs:     true,
+		IncludePipelineRuns: true,
+		IncludeTaskRuns:     true,
+	})
+	assert.NoError(t, err)
+
+	jobs, err := prowClient.ProwV1().ProwJobs("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, jobs.Items)
+
+	runs, err := tektonClient.TektonV1alpha1().PipelineRuns("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, runs.Items)
+
+	taskRuns, err := tektonClient.TektonV1alpha1().TaskRuns("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, taskRuns.Items)
+}
+
+func TestGCSkipsRunningProwJobsByDefault(t *testing.T) {
+	prowClient := prowfake.NewSimpleClientset(&prowjobsv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-running", Namespace: "jx"},
+		Status:     prowjobsv1.ProwJobStatus{CompletionTime: nil},
+	})
+	tektonClient := tektonfake.NewSimpleClientset()
+
+	err := prow.GC(prowClient, tektonClient, "jx", prow.GCOptions{
+		KeepSince:       0,
+		IgnoreRunning:   false,
+		IncludeProwJobs: true,
+	})
+	assert.NoError(t, err)
+
+	jobs, err := prowClient.ProwV1().ProwJobs("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, jobs.Items, 1, "a still-running ProwJob should not be deleted unless IgnoreRunning is set")
+}
+
+func TestGCDryRunDeletesNothing(t *testing.T) {
+	old := metav1.NewTime(time.Now().Add(-48 * time.Hour))
+
+	prowClient := prowfake.NewSimpleClientset(&prowjobsv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-1", Namespace: "jx"},
+		Status:     prowjobsv1.ProwJobStatus{CompletionTime: &old},
+	})
+
+	tektonClient := tektonfake.NewSimpleClientset(
+		&pipelinev1alpha1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "pr-1", Namespace: "jx", Labels: map[string]string{"prow.k8s.io/id": "job-1"}},
+		},
+		&pipelinev1alpha1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "tr-1", Namespace: "jx", Labels: map[string]string{"tekton.dev/pipelineRun": "pr-1"}},
+		},
+	)
+
+	err := prow.GC(prowClient, tektonClient, "jx", prow.GCOptions{
+		KeepSince:           24 * time.Hour,
+		IncludeProwJobs:     true,
+		IncludePipelineRuns: true,
+		IncludeTaskRuns:     true,
+		DryRun:              true,
+	})
+	assert.NoError(t, err)
+
+	jobs, err := prowClient.ProwV1().ProwJobs("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, jobs.Items, 1, "--dry-run must not delete ProwJobs")
+
+	runs, err := tektonClient.TektonV1alpha1().PipelineRuns("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, runs.Items, 1, "--dry-run must not delete PipelineRuns")
+
+	taskRuns, err := tektonClient.TektonV1alpha1().TaskRuns("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, taskRuns.Items, 1, "--dry-run must not delete TaskRuns")
+}
+
+func TestGCKeepsProwJobsNewerThanKeepSince(t *testing.T) {
+	recent := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	prowClient := prowfake.NewSimpleClientset(&prowjobsv1.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "job-recent", Namespace: "jx"},
+		Status:     prowjobsv1.ProwJobStatus{CompletionTime: &recent},
+	})
+	tektonClient := tektonfake.NewSimpleClientset()
+
+	err := prow.GC(prowClient, tektonClient, "jx", prow.GCOptions{
+		KeepSince:       24 * time.Hour,
+		IncludeProwJobs: true,
+	})
+	assert.NoError(t, err)
+
+	jobs, err := prowClient.ProwV1().ProwJobs("jx").List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, jobs.Items, 1, "a recently completed ProwJob should be kept until it is older than keep-since")
+}